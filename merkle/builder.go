@@ -0,0 +1,93 @@
+package merkle
+
+import (
+	"errors"
+	"io"
+)
+
+// Builder incrementally constructs a Tree from a stream of leaves without
+// requiring the caller to materialize every value up front. It keeps a
+// stack of subtree roots and collapses the top two as soon as they sit at
+// the same level (the same pushStack/finalizeStack accumulator root and
+// Tree.Verify build over Leaves), so pushing n leaves costs O(log n) memory
+// rather than O(n), and a Builder-built Tree's root matches NewTree's for
+// the same ordered leaves.
+type Builder struct {
+	opts  Options
+	stack []*stackNode
+}
+
+// NewBuilder creates a Builder that hashes pushed values using opts.
+func NewBuilder(opts Options) *Builder {
+	return &Builder{opts: opts}
+}
+
+// Push hashes value as a new leaf and folds it into the tree being built.
+func (b *Builder) Push(value []byte) {
+	b.stack = pushStack(b.stack, &Node{Typ: Leaf, Hash: b.opts.hashLeaf(value)}, b.opts)
+}
+
+// PushHash folds in a leaf whose hash the caller has already computed,
+// skipping the leaf hashing step.
+func (b *Builder) PushHash(h []byte) {
+	b.stack = pushStack(b.stack, &Node{Typ: Leaf, Hash: h}, b.opts)
+}
+
+// Finalize collapses whatever subtree roots are left on the stack into a
+// single root and returns the resulting Tree. The Builder must not be used
+// after Finalize is called.
+func (b *Builder) Finalize() *Tree {
+	roots := make([]*Node, len(b.stack))
+	for i, s := range b.stack {
+		roots[i] = s.node
+	}
+
+	var leaves []*Node
+	collectLeaves(roots, &leaves)
+
+	return &Tree{
+		Root:   finalizeStack(b.stack, b.opts),
+		Leaves: leaves,
+		opts:   b.opts,
+	}
+}
+
+func collectLeaves(nodes []*Node, out *[]*Node) {
+	for _, n := range nodes {
+		if n.Typ == Leaf {
+			*out = append(*out, n)
+			continue
+		}
+		collectLeaves([]*Node{n.Left, n.Right}, out)
+	}
+}
+
+// BuildFromReader hashes r in fixed-size segments of segmentSize bytes,
+// pushing each segment into a Builder as it is read, so a Merkle root can be
+// computed over a file or network stream without loading it into memory.
+func BuildFromReader(r io.Reader, segmentSize int64) (*Tree, error) {
+	if segmentSize <= 0 {
+		return nil, errors.New("segmentSize must be positive")
+	}
+
+	b := NewBuilder(Options{})
+	buf := make([]byte, segmentSize)
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			segment := make([]byte, n)
+			copy(segment, buf[:n])
+			b.Push(segment)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Finalize(), nil
+}
@@ -0,0 +1,105 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+)
+
+// CachedTree is a Tree that supports appending new leaves and updating
+// existing ones by recomputing only the nodes on the affected path,
+// instead of rebuilding the whole tree from Leaves the way Tree.Verify
+// does. Internally it keeps the same stack of subtree roots Builder uses
+// (and the same pushStack/finalizeStack accumulator root builds from), so
+// appending N leaves to an existing tree costs O(N + log n) hashes instead
+// of O(N + n), UpdateLeaf costs O(log n), and a CachedTree's root always
+// matches NewTree's for the same ordered leaves.
+type CachedTree struct {
+	opts   Options
+	stack  []*stackNode
+	leaves []*Node
+}
+
+// NewCachedTree builds a CachedTree from values, ready for further Append
+// and UpdateLeaf calls.
+func NewCachedTree(values [][]byte, opts Options) *CachedTree {
+	t := &CachedTree{opts: opts}
+	for _, v := range values {
+		t.Append(v)
+	}
+	return t
+}
+
+// Append hashes value as a new leaf and folds it into the tree, touching
+// only the O(log n) nodes on the stack.
+func (t *CachedTree) Append(value []byte) {
+	node := &Node{Typ: Leaf, Hash: t.opts.hashLeaf(value)}
+	t.leaves = append(t.leaves, node)
+	t.stack = pushStack(t.stack, node, t.opts)
+}
+
+// UpdateLeaf replaces the value of the leaf at index, recomputing only the
+// O(log n) ancestors on its path within its stack subtree.
+func (t *CachedTree) UpdateLeaf(index int, value []byte) error {
+	if index < 0 || index >= len(t.leaves) {
+		return errors.New("merkle: index out of range")
+	}
+
+	n := t.leaves[index]
+	n.Hash = t.opts.hashLeaf(value)
+
+	for p := n.Parent; p != nil; p = p.Parent {
+		p.Hash = t.opts.hashNode(p.Left.Hash, p.Right.Hash)
+	}
+
+	return nil
+}
+
+// Root returns the hash of the tree's current root, collapsing the
+// remaining stack entries exactly as finalizeStack does. It uses the
+// non-mutating finalizeStackPeek, since unlike Builder.Finalize this isn't
+// a terminal call: the stack may still be folded differently once a later
+// Append arrives.
+func (t *CachedTree) Root() []byte {
+	return finalizeStackPeek(t.stack, t.opts)
+}
+
+// Proof builds a proof for the leaf at index.
+func (t *CachedTree) Proof(index int) (Proof, error) {
+	if index < 0 || index >= len(t.leaves) {
+		return nil, errors.New("merkle: index out of range")
+	}
+
+	leaf := t.leaves[index]
+	path := proofFromNode(leaf)
+
+	top := leaf
+	for top.Parent != nil {
+		top = top.Parent
+	}
+
+	stackIdx := -1
+	for i, s := range t.stack {
+		if s.node == top {
+			stackIdx = i
+			break
+		}
+	}
+
+	crossing := finalizeStackProofPeek(t.stack, stackIdx, t.opts)
+	return append(path, crossing...), nil
+}
+
+// VerifyProof verifies that h is part of the tree given its current root.
+func (t *CachedTree) VerifyProof(h []byte, path []PathPoint) bool {
+	result := h
+
+	for _, point := range path {
+		if point.Appended {
+			result = t.opts.hashNode(result, point.Hash)
+		} else {
+			result = t.opts.hashNode(point.Hash, result)
+		}
+	}
+
+	return bytes.Equal(t.Root(), result)
+}
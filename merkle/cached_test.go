@@ -0,0 +1,49 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+func benchValues(n int) [][]byte {
+	values := make([][]byte, n)
+	for i := range values {
+		values[i] = make([]byte, 8)
+		binary.LittleEndian.PutUint64(values[i], uint64(i))
+	}
+	return values
+}
+
+// BenchmarkCachedTree_Append and BenchmarkNewTree_Rebuild compare the two
+// ways of adding one leaf to an existing tree of n leaves: CachedTree.Append
+// touches only the O(log n) nodes on the new leaf's path, while rebuilding
+// via NewTree re-hashes all n+1 leaves from scratch.
+func BenchmarkCachedTree_Append(b *testing.B) {
+	for _, n := range []int{1 << 8, 1 << 12, 1 << 16} {
+		values := benchValues(n)
+		extra := benchValues(1)[0]
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			t := NewCachedTree(values, Options{})
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				t.Append(extra)
+			}
+		})
+	}
+}
+
+func BenchmarkNewTree_Rebuild(b *testing.B) {
+	for _, n := range []int{1 << 8, 1 << 12, 1 << 16} {
+		values := benchValues(n)
+		values = append(values, benchValues(1)[0])
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewTree(values)
+			}
+		})
+	}
+}
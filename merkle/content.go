@@ -0,0 +1,87 @@
+package merkle
+
+import (
+	"errors"
+	"hash"
+)
+
+// Content is implemented by domain objects that can be stored directly as
+// Tree leaves instead of raw []byte values, so they can be looked up by
+// domain equality rather than by hash bytes.
+type Content interface {
+	CalculateHash() ([]byte, error)
+	Equals(other Content) (bool, error)
+}
+
+// NewTreeFromContent constructs a new MerkleTree from the given Content
+// items. Each leaf hash is taken directly from item.CalculateHash(); hashFn
+// is used to combine hashes at internal nodes (sha512.New is used if hashFn
+// is nil).
+func NewTreeFromContent(items []Content, hashFn func() hash.Hash) (*Tree, error) {
+	opts := Options{Hash: hashFn}
+
+	leaves := make([]*Node, len(items))
+	for i, item := range items {
+		h, err := item.CalculateHash()
+		if err != nil {
+			return nil, err
+		}
+
+		leaves[i] = &Node{
+			Typ:     Leaf,
+			Hash:    h,
+			Content: item,
+		}
+	}
+
+	return &Tree{
+		Root:   root(append([]*Node(nil), leaves...), opts),
+		Leaves: leaves,
+		opts:   opts,
+	}, nil
+}
+
+// ProofForContent builds a proof for the leaf holding c, looking it up by
+// Content equality rather than by hash bytes.
+//
+// This stays a parallel entry point rather than an overload of Proof:
+// Proof/VerifyProof/findNodeWithHash are hash-only and PersistentTree,
+// CachedTree and SparseTree all call them (or the stack helpers behind
+// them) expecting a []byte key, not a Content. Making Tree.Proof accept
+// either would mean every one of those callers carries dead Content
+// plumbing for a case that can never apply to them. A tree built with
+// NewTreeFromContent can still be looked up by hash via Proof, since
+// CalculateHash() is exactly that hash; ProofForContent only adds the
+// Equals-based lookup on top.
+func (tree *Tree) ProofForContent(c Content) (Proof, error) {
+	if tree == nil {
+		return nil, errors.New("empty tree")
+	}
+
+	current, err := findNodeWithContent(tree.Leaves, c)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, errors.New("no node with such content")
+	}
+
+	return proofFromNode(current), nil
+}
+
+func findNodeWithContent(nodes []*Node, c Content) (*Node, error) {
+	for _, n := range nodes {
+		if n.Content == nil {
+			continue
+		}
+
+		eq, err := n.Content.Equals(c)
+		if err != nil {
+			return nil, err
+		}
+		if eq {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
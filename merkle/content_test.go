@@ -0,0 +1,70 @@
+package merkle
+
+import (
+	"errors"
+	"testing"
+)
+
+var errCustomEquals = errors.New("stringContent: forced Equals failure")
+
+type stringContent struct {
+	v         string
+	equalsErr error
+}
+
+func (c stringContent) CalculateHash() ([]byte, error) {
+	return []byte(c.v), nil
+}
+
+func (c stringContent) Equals(other Content) (bool, error) {
+	if c.equalsErr != nil {
+		return false, c.equalsErr
+	}
+	o, ok := other.(stringContent)
+	if !ok {
+		return false, nil
+	}
+	return c.v == o.v, nil
+}
+
+func TestProofForContent_LooksUpLeafByEquals(t *testing.T) {
+	items := []Content{stringContent{v: "a"}, stringContent{v: "b"}, stringContent{v: "c"}}
+
+	tree, err := NewTreeFromContent(items, nil)
+	if err != nil {
+		t.Fatalf("NewTreeFromContent: %v", err)
+	}
+
+	proof, err := tree.ProofForContent(stringContent{v: "b"})
+	if err != nil {
+		t.Fatalf("ProofForContent: %v", err)
+	}
+
+	leafHash, err := items[1].CalculateHash()
+	if err != nil {
+		t.Fatalf("CalculateHash: %v", err)
+	}
+	if !tree.VerifyProof(leafHash, proof) {
+		t.Fatal("proof built via ProofForContent failed to verify against the tree's root")
+	}
+
+	if _, err := tree.ProofForContent(stringContent{v: "missing"}); err == nil {
+		t.Fatal("ProofForContent succeeded for content that was never stored")
+	}
+}
+
+func TestProofForContent_PropagatesEqualsError(t *testing.T) {
+	items := []Content{
+		stringContent{v: "a"},
+		stringContent{v: "b", equalsErr: errCustomEquals},
+	}
+
+	tree, err := NewTreeFromContent(items, nil)
+	if err != nil {
+		t.Fatalf("NewTreeFromContent: %v", err)
+	}
+
+	if _, err := tree.ProofForContent(stringContent{v: "anything"}); err != errCustomEquals {
+		t.Fatalf("ProofForContent error = %v, want the error returned by Equals", err)
+	}
+}
@@ -0,0 +1,52 @@
+package merkle
+
+import (
+	"crypto/sha512"
+	"hash"
+)
+
+// Domain separation prefixes as defined by RFC 6962 section 2.1: leaf hashes
+// and internal node hashes are computed over disjoint input spaces so that a
+// leaf hash can never be presented as a valid internal node hash.
+const (
+	rfc6962LeafPrefix = 0x00
+	rfc6962NodePrefix = 0x01
+)
+
+// Options configures how a Tree computes and verifies hashes.
+type Options struct {
+	// Hash is the hash function factory used to hash leaves and internal
+	// nodes. Defaults to sha512.New when nil.
+	Hash func() hash.Hash
+	// RFC6962 enables RFC 6962 (Certificate Transparency) compatible
+	// domain-separated hashing: leaf hashes are computed as H(0x00 || value)
+	// and internal hashes as H(0x01 || left || right), so a leaf hash can
+	// never be forged as an internal node hash.
+	RFC6962 bool
+}
+
+func (o Options) hashFn() func() hash.Hash {
+	if o.Hash != nil {
+		return o.Hash
+	}
+	return sha512.New
+}
+
+func (o Options) hashLeaf(value []byte) []byte {
+	h := o.hashFn()()
+	if o.RFC6962 {
+		h.Write([]byte{rfc6962LeafPrefix})
+	}
+	h.Write(value)
+	return h.Sum(nil)
+}
+
+func (o Options) hashNode(left, right []byte) []byte {
+	h := o.hashFn()()
+	if o.RFC6962 {
+		h.Write([]byte{rfc6962NodePrefix})
+	}
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -0,0 +1,59 @@
+package merkle
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestRFC6962_PreventsLeafNodeConfusion pins the exact attack RFC 6962
+// domain separation exists to prevent: without it, H(childA||childB) (an
+// internal node's hash) equals H(v) for a forged single leaf value
+// v = childA||childB, so an attacker could claim a 1-leaf tree shares a
+// 2-leaf tree's root. Prefixing leaves with 0x00 and internal nodes with
+// 0x01 must make that collision impossible to reproduce.
+func TestRFC6962_PreventsLeafNodeConfusion(t *testing.T) {
+	plain := Options{}
+	a, b := plain.hashLeaf([]byte("a")), plain.hashLeaf([]byte("b"))
+	root := plain.hashNode(a, b)
+	forgedLeaf := plain.hashLeaf(append(append([]byte(nil), a...), b...))
+	if !bytes.Equal(root, forgedLeaf) {
+		t.Fatal("sanity check failed: expected the classic leaf/node confusion to reproduce without domain separation")
+	}
+
+	rfc := Options{RFC6962: true}
+	ra, rb := rfc.hashLeaf([]byte("a")), rfc.hashLeaf([]byte("b"))
+	rfcRoot := rfc.hashNode(ra, rb)
+	rfcForgedLeaf := rfc.hashLeaf(append(append([]byte(nil), ra...), rb...))
+	if bytes.Equal(rfcRoot, rfcForgedLeaf) {
+		t.Fatal("RFC6962 domain separation did not prevent a leaf hash from being replayed as an internal node hash")
+	}
+}
+
+// TestRFC6962_TreeRootsDifferFromPlainHashing pins that enabling RFC6962
+// actually changes what gets hashed, not just that it's wired through:
+// the same leaves produce a different root with and without it.
+func TestRFC6962_TreeRootsDifferFromPlainHashing(t *testing.T) {
+	plain := NewTreeWithOptions(valsN(5), Options{})
+	rfc := NewTreeWithOptions(valsN(5), Options{RFC6962: true})
+
+	if bytes.Equal(plain.Root.Hash, rfc.Root.Hash) {
+		t.Fatal("RFC6962 mode produced the same root as plain hashing for the same leaves")
+	}
+}
+
+// TestOddLeafCount_DoesNotDuplicateTrailingLeaf pins that root folds an
+// unpaired trailing subtree in unchanged rather than padding the tree by
+// pairing it with a duplicate of itself: {a,b,c} and {a,b,c,c} must not
+// collapse to the same root just because the input was padded to a power
+// of two.
+func TestOddLeafCount_DoesNotDuplicateTrailingLeaf(t *testing.T) {
+	three := valsN(3)
+	padded := append(append([][]byte(nil), three...), three[2])
+
+	abc := NewTree(three)
+	abcc := NewTree(padded)
+
+	if bytes.Equal(abc.Root.Hash, abcc.Root.Hash) {
+		t.Fatal("{a,b,c} and {a,b,c,c} produced the same root; an odd trailing leaf must not be silently duplicated to pad the tree")
+	}
+}
@@ -0,0 +1,266 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by a KVStore when a key does not exist.
+var ErrNotFound = errors.New("merkle: key not found")
+
+// KVStore is the minimal key-value store PersistentTree needs to persist
+// nodes keyed by their hash. Adapters for LevelDB, BoltDB, or any other
+// backing store need only implement this interface.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+}
+
+// MemoryKVStore is an in-memory KVStore. It is mainly useful for tests, and
+// for trees that fit comfortably in RAM but still want the PersistentTree
+// API (e.g. to be reopened from a stored root hash later).
+type MemoryKVStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryKVStore creates an empty MemoryKVStore.
+func NewMemoryKVStore() *MemoryKVStore {
+	return &MemoryKVStore{data: make(map[string][]byte)}
+}
+
+func (s *MemoryKVStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *MemoryKVStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[string(key)] = value
+	return nil
+}
+
+// PersistentTree mirrors Tree but keeps nodes in a KVStore keyed by hash
+// instead of holding every *Node alive, loading children lazily by hash on
+// demand. Add keeps only the O(log n) pending subtree roots (the same
+// pushStack/finalizeStack accumulator Builder and CachedTree use) in
+// memory between calls, persisting each new node as it's combined, so
+// building a tree costs O(log n) new hashes per Add rather than re-hashing
+// every node seen so far. leafHashes still grows with every leaf (it's
+// what OpenPersistentTree needs to resume a tree), but it holds only raw
+// hashes, never whole *Node subtrees.
+type PersistentTree struct {
+	store KVStore
+	opts  Options
+
+	rootHash   []byte
+	leafHashes [][]byte
+	stack      []*stackNode
+}
+
+// NewPersistentTree creates an empty PersistentTree that persists every
+// node it builds to store as values are added via Add.
+func NewPersistentTree(store KVStore, opts Options) *PersistentTree {
+	return &PersistentTree{store: store, opts: opts}
+}
+
+// OpenPersistentTree reopens a PersistentTree previously written to store,
+// from its root hash and the ordered hashes of its leaves, without eagerly
+// reading any other node. Its in-memory pushStack accumulator is rebuilt
+// lazily, the first time Add is called on it.
+func OpenPersistentTree(store KVStore, opts Options, rootHash []byte, leafHashes [][]byte) *PersistentTree {
+	return &PersistentTree{
+		store:      store,
+		opts:       opts,
+		rootHash:   rootHash,
+		leafHashes: append([][]byte(nil), leafHashes...),
+	}
+}
+
+// Root returns the hash of the tree's current root node.
+func (t *PersistentTree) Root() []byte {
+	return t.rootHash
+}
+
+// Add hashes value as a new leaf and folds it into the pending stack of
+// subtree roots, persisting only the newly created nodes, so appending N
+// leaves costs O(N log n) hashes and O(log n) peak new allocations per
+// call, instead of rebuilding and re-persisting the whole tree each time.
+func (t *PersistentTree) Add(value []byte) error {
+	t.ensureStack()
+
+	leaf := &Node{Typ: Leaf, Hash: t.opts.hashLeaf(value)}
+	if err := t.store.Put(leaf.Hash, encodeNode(leaf)); err != nil {
+		return err
+	}
+
+	var persistErr error
+	persist := func(n *Node) {
+		if persistErr == nil {
+			persistErr = t.store.Put(n.Hash, encodeNode(n))
+		}
+	}
+
+	t.stack = pushStackNotify(t.stack, leaf, t.opts, persist)
+	if persistErr != nil {
+		return persistErr
+	}
+
+	r := finalizeStackNotify(t.stack, t.opts, persist)
+	if persistErr != nil {
+		return persistErr
+	}
+
+	t.rootHash = r.Hash
+	t.leafHashes = append(t.leafHashes, leaf.Hash)
+	return nil
+}
+
+// ensureStack rebuilds the pushStack accumulator from leafHashes the first
+// time Add is called on a tree reopened via OpenPersistentTree; every node
+// it touches is already in store, so this replays hashing only, no writes.
+func (t *PersistentTree) ensureStack() {
+	if t.stack != nil || len(t.leafHashes) == 0 {
+		return
+	}
+
+	for _, h := range t.leafHashes {
+		t.stack = pushStack(t.stack, &Node{Typ: Leaf, Hash: h}, t.opts)
+	}
+}
+
+// Proof builds a proof for the leaf with hash h. It searches depth-first
+// from the root, descending into the left subtree before the right, so it
+// loads every node on that search path rather than just the O(log n) nodes
+// on h's direct path to the root — O(n) loads in the worst case, e.g. a
+// leaf that is last in tree order.
+func (t *PersistentTree) Proof(h []byte) (Proof, error) {
+	root, err := t.load(t.rootHash)
+	if err != nil {
+		return nil, err
+	}
+
+	path, found, err := t.search(root, h)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("no node with such hash")
+	}
+
+	return path, nil
+}
+
+func (t *PersistentTree) search(n *Node, h []byte) ([]PathPoint, bool, error) {
+	if n.Typ == Leaf {
+		return nil, bytes.Equal(n.Hash, h), nil
+	}
+
+	left, err := t.load(n.Left.Hash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if path, found, err := t.search(left, h); err != nil {
+		return nil, false, err
+	} else if found {
+		return append(path, PathPoint{Hash: n.Right.Hash, Appended: true}), true, nil
+	}
+
+	right, err := t.load(n.Right.Hash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if path, found, err := t.search(right, h); err != nil {
+		return nil, false, err
+	} else if found {
+		return append(path, PathPoint{Hash: n.Left.Hash, Appended: false}), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// VerifyProof verifies that h is part of the tree, the same way Tree does.
+func (t *PersistentTree) VerifyProof(h []byte, path []PathPoint) bool {
+	result := h
+
+	for _, point := range path {
+		if point.Appended {
+			result = t.opts.hashNode(result, point.Hash)
+		} else {
+			result = t.opts.hashNode(point.Hash, result)
+		}
+	}
+
+	return bytes.Equal(t.rootHash, result)
+}
+
+func (t *PersistentTree) load(hash []byte) (*Node, error) {
+	data, err := t.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return decodeNode(hash, data)
+}
+
+// encodeNode/decodeNode define the on-disk layout of a node: a type byte,
+// and for internal nodes a uvarint-prefixed left child hash followed by the
+// right child hash (the node's own hash is the store key, so it is not
+// repeated in the value).
+func encodeNode(n *Node) []byte {
+	if n.Typ == Leaf {
+		return []byte{byte(Leaf)}
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	lenN := binary.PutUvarint(lenBuf, uint64(len(n.Left.Hash)))
+
+	buf := make([]byte, 0, 1+lenN+len(n.Left.Hash)+len(n.Right.Hash))
+	buf = append(buf, byte(TreeNode))
+	buf = append(buf, lenBuf[:lenN]...)
+	buf = append(buf, n.Left.Hash...)
+	buf = append(buf, n.Right.Hash...)
+
+	return buf
+}
+
+func decodeNode(hash, data []byte) (*Node, error) {
+	if len(data) == 0 {
+		return nil, errors.New("merkle: corrupt node encoding")
+	}
+
+	typ := NodeType(data[0])
+	if typ == Leaf {
+		return &Node{Typ: Leaf, Hash: hash}, nil
+	}
+
+	leftLen, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return nil, errors.New("merkle: corrupt node encoding")
+	}
+
+	rest := data[1+n:]
+	if uint64(len(rest)) < leftLen {
+		return nil, errors.New("merkle: corrupt node encoding")
+	}
+
+	return &Node{
+		Typ:  TreeNode,
+		Hash: hash,
+		Relationships: Relationships{
+			Left:  &Node{Hash: rest[:leftLen]},
+			Right: &Node{Hash: rest[leftLen:]},
+		},
+	}, nil
+}
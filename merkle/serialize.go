@@ -0,0 +1,330 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"reflect"
+)
+
+// Proof is a sequence of PathPoints from a leaf up to a tree's root, as
+// returned by Tree.Proof, Tree.ProofForContent, CachedTree.Proof,
+// PersistentTree.Proof and SparseTree.ProveInclusion.
+type Proof []PathPoint
+
+// MarshalBinary encodes p as a compact wire format: a header byte holding
+// the sibling hash size in bytes, a uvarint path length, a bitfield of
+// Appended flags (one bit per step, packed MSB-first into ceil(len/8)
+// bytes), and finally the concatenated sibling hashes.
+func (p Proof) MarshalBinary() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte{0, 0}, nil
+	}
+
+	hashSize := len(p[0].Hash)
+	if hashSize > 255 {
+		return nil, errors.New("merkle: hash size too large to serialize")
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	lenN := binary.PutUvarint(lenBuf, uint64(len(p)))
+
+	buf := make([]byte, 0, 1+lenN+(len(p)+7)/8+len(p)*hashSize)
+	buf = append(buf, byte(hashSize))
+	buf = append(buf, lenBuf[:lenN]...)
+
+	bitfield := make([]byte, (len(p)+7)/8)
+	for i, point := range p {
+		if len(point.Hash) != hashSize {
+			return nil, errors.New("merkle: inconsistent hash size in proof")
+		}
+		if point.Appended {
+			bitfield[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	buf = append(buf, bitfield...)
+
+	for _, point := range p {
+		buf = append(buf, point.Hash...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a Proof previously produced by MarshalBinary.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("merkle: truncated proof encoding")
+	}
+
+	hashSize := int(data[0])
+	rest := data[1:]
+
+	pathLen, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return errors.New("merkle: truncated proof encoding")
+	}
+	rest = rest[n:]
+
+	if pathLen == 0 {
+		*p = nil
+		return nil
+	}
+
+	bitfieldLen := (int(pathLen) + 7) / 8
+	if len(rest) < bitfieldLen {
+		return errors.New("merkle: truncated proof encoding")
+	}
+	bitfield := rest[:bitfieldLen]
+	rest = rest[bitfieldLen:]
+
+	if uint64(len(rest)) != pathLen*uint64(hashSize) {
+		return errors.New("merkle: truncated proof encoding")
+	}
+
+	path := make([]PathPoint, pathLen)
+	for i := range path {
+		h := append([]byte(nil), rest[i*hashSize:(i+1)*hashSize]...)
+		path[i] = PathPoint{
+			Hash:     h,
+			Appended: bitfield[i/8]&(1<<uint(7-i%8)) != 0,
+		}
+	}
+
+	*p = path
+	return nil
+}
+
+// MarshalJSON encodes p as its ordered []PathPoint, the same shape used
+// throughout this package.
+func (p Proof) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]PathPoint(p))
+}
+
+// UnmarshalJSON decodes a Proof previously produced by MarshalJSON.
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var path []PathPoint
+	if err := json.Unmarshal(data, &path); err != nil {
+		return err
+	}
+	*p = path
+	return nil
+}
+
+// MarshalBinary encodes tree as a header byte of flags (bit 0 is RFC6962),
+// a header byte identifying the hash algorithm, a uvarint leaf count, and
+// the concatenated ordered leaf hashes, so Verify can reconstruct and check
+// the root after a round trip.
+func (tree *Tree) MarshalBinary() ([]byte, error) {
+	if tree == nil {
+		return nil, errors.New("merkle: nil tree")
+	}
+
+	algo, err := algoForHashFn(tree.opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	hashSize := tree.opts.hashFn()().Size()
+
+	var flags byte
+	if tree.opts.RFC6962 {
+		flags |= 0x01
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	lenN := binary.PutUvarint(lenBuf, uint64(len(tree.Leaves)))
+
+	buf := make([]byte, 0, 2+lenN+len(tree.Leaves)*hashSize)
+	buf = append(buf, flags, byte(algo))
+	buf = append(buf, lenBuf[:lenN]...)
+
+	for _, leaf := range tree.Leaves {
+		if len(leaf.Hash) != hashSize {
+			return nil, errors.New("merkle: leaf hash size mismatch")
+		}
+		buf = append(buf, leaf.Hash...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a Tree previously produced by MarshalBinary,
+// rebuilding Root from the stored leaf hashes and options.
+func (tree *Tree) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("merkle: truncated tree encoding")
+	}
+
+	flags := data[0]
+	hashFn, err := hashFnForAlgo(algoID(data[1]))
+	if err != nil {
+		return err
+	}
+	hashSize := hashFn().Size()
+	rest := data[2:]
+
+	leafCount, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return errors.New("merkle: truncated tree encoding")
+	}
+	rest = rest[n:]
+
+	if uint64(len(rest)) != leafCount*uint64(hashSize) {
+		return errors.New("merkle: truncated tree encoding")
+	}
+
+	opts := Options{Hash: hashFn, RFC6962: flags&0x01 != 0}
+
+	leaves := make([]*Node, leafCount)
+	for i := range leaves {
+		h := append([]byte(nil), rest[i*hashSize:(i+1)*hashSize]...)
+		leaves[i] = &Node{Typ: Leaf, Hash: h}
+	}
+
+	tree.opts = opts
+	tree.Leaves = leaves
+	tree.Root = root(leaves, opts)
+
+	return nil
+}
+
+// treeJSON is the JSON wire representation of a Tree: the ordered leaf
+// hashes plus the options needed to rebuild and verify it, matching what
+// MarshalBinary/UnmarshalBinary encode.
+type treeJSON struct {
+	RFC6962 bool     `json:"rfc6962"`
+	Algo    string   `json:"algo"`
+	Leaves  [][]byte `json:"leaves"`
+}
+
+// MarshalJSON encodes tree the same way MarshalBinary does: the ordered
+// leaf hashes plus the options needed to rebuild and verify it.
+func (tree *Tree) MarshalJSON() ([]byte, error) {
+	if tree == nil {
+		return nil, errors.New("merkle: nil tree")
+	}
+
+	algo, err := algoForHashFn(tree.opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	name, err := algoName(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([][]byte, len(tree.Leaves))
+	for i, leaf := range tree.Leaves {
+		leaves[i] = leaf.Hash
+	}
+
+	return json.Marshal(treeJSON{
+		RFC6962: tree.opts.RFC6962,
+		Algo:    name,
+		Leaves:  leaves,
+	})
+}
+
+// UnmarshalJSON decodes a Tree previously produced by MarshalJSON,
+// rebuilding Root from the stored leaf hashes and options.
+func (tree *Tree) UnmarshalJSON(data []byte) error {
+	var tj treeJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+
+	algo, err := algoByName(tj.Algo)
+	if err != nil {
+		return err
+	}
+	hashFn, err := hashFnForAlgo(algo)
+	if err != nil {
+		return err
+	}
+
+	opts := Options{Hash: hashFn, RFC6962: tj.RFC6962}
+
+	leaves := make([]*Node, len(tj.Leaves))
+	for i, h := range tj.Leaves {
+		leaves[i] = &Node{Typ: Leaf, Hash: h}
+	}
+
+	tree.opts = opts
+	tree.Leaves = leaves
+	tree.Root = root(leaves, opts)
+
+	return nil
+}
+
+// algoID identifies a hash function factory in the binary and JSON tree
+// encodings. Identifying the algorithm itself, rather than just its output
+// size, matters because two different algorithms can produce same-size
+// hashes (e.g. SHA-256 and a 256-bit variant of another function): encoding
+// only the size would let a tree round-trip silently mis-hashed, so Verify
+// would fail with no indication why.
+type algoID byte
+
+const (
+	algoSHA512 algoID = iota
+	algoSHA256
+)
+
+// algoForHashFn identifies fn as one of the hash function factories this
+// package recognizes. fn is compared by function pointer identity rather
+// than by the size of the hash it produces, since sameSize algorithms would
+// otherwise be confused for one another. A nil fn is Options' default,
+// sha512.New.
+func algoForHashFn(fn func() hash.Hash) (algoID, error) {
+	if fn == nil {
+		fn = sha512.New
+	}
+
+	switch reflect.ValueOf(fn).Pointer() {
+	case reflect.ValueOf(sha512.New).Pointer():
+		return algoSHA512, nil
+	case reflect.ValueOf(sha256.New).Pointer():
+		return algoSHA256, nil
+	default:
+		return 0, errors.New("merkle: hash function is not one of the algorithms this package can serialize")
+	}
+}
+
+// hashFnForAlgo is the inverse of algoForHashFn.
+func hashFnForAlgo(id algoID) (func() hash.Hash, error) {
+	switch id {
+	case algoSHA512:
+		return sha512.New, nil
+	case algoSHA256:
+		return sha256.New, nil
+	default:
+		return nil, fmt.Errorf("merkle: unsupported hash algorithm id %d for serialization", id)
+	}
+}
+
+// algoName and algoByName map algoID to and from the stable names used by
+// the JSON encoding.
+func algoName(id algoID) (string, error) {
+	switch id {
+	case algoSHA512:
+		return "sha512", nil
+	case algoSHA256:
+		return "sha256", nil
+	default:
+		return "", fmt.Errorf("merkle: unsupported hash algorithm id %d for serialization", id)
+	}
+}
+
+func algoByName(name string) (algoID, error) {
+	switch name {
+	case "sha512":
+		return algoSHA512, nil
+	case "sha256":
+		return algoSHA256, nil
+	default:
+		return 0, fmt.Errorf("merkle: unsupported hash algorithm %q for serialization", name)
+	}
+}
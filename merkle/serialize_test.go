@@ -0,0 +1,215 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+)
+
+func TestProof_BinaryRoundTrip(t *testing.T) {
+	nt := NewTree(valsN(9))
+	proof, err := nt.Proof(nt.Leaves[3].Hash)
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+
+	data, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var decoded Proof
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if len(decoded) != len(proof) {
+		t.Fatalf("round-tripped proof has %d steps, want %d", len(decoded), len(proof))
+	}
+	for i := range proof {
+		if !bytes.Equal(decoded[i].Hash, proof[i].Hash) || decoded[i].Appended != proof[i].Appended {
+			t.Fatalf("step %d: got %+v, want %+v", i, decoded[i], proof[i])
+		}
+	}
+	if !nt.VerifyProof(nt.Leaves[3].Hash, decoded) {
+		t.Fatal("round-tripped proof failed to verify")
+	}
+}
+
+// TestProof_BinaryAppendedBitfieldPacking pins the packed-bitfield layout:
+// Appended flags are packed MSB-first, one bit per step, into ceil(len/8)
+// bytes, so a path that isn't a multiple of 8 steps still round-trips.
+func TestProof_BinaryAppendedBitfieldPacking(t *testing.T) {
+	hash := func(b byte) []byte { return bytes.Repeat([]byte{b}, sha256.Size) }
+
+	proof := Proof{
+		{Hash: hash(1), Appended: true},
+		{Hash: hash(2), Appended: false},
+		{Hash: hash(3), Appended: true},
+		{Hash: hash(4), Appended: true},
+		{Hash: hash(5), Appended: false},
+		{Hash: hash(6), Appended: true},
+		{Hash: hash(7), Appended: false},
+		{Hash: hash(8), Appended: true},
+		{Hash: hash(9), Appended: true},
+	}
+
+	data, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	// header byte (hash size) + uvarint path length (9 -> 1 byte) + bitfield
+	// packed into ceil(9/8) = 2 bytes.
+	bitfieldStart := 1 + 1
+	wantBitfield := []byte{0b10110101, 0b10000000}
+	gotBitfield := data[bitfieldStart : bitfieldStart+2]
+	if !bytes.Equal(gotBitfield, wantBitfield) {
+		t.Fatalf("packed bitfield = %08b, want %08b", gotBitfield, wantBitfield)
+	}
+
+	var decoded Proof
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	for i := range proof {
+		if decoded[i].Appended != proof[i].Appended {
+			t.Fatalf("step %d: Appended = %v, want %v", i, decoded[i].Appended, proof[i].Appended)
+		}
+	}
+}
+
+func TestProof_EmptyBinaryRoundTrip(t *testing.T) {
+	var proof Proof
+
+	data, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	decoded := Proof{{Hash: []byte("not empty")}}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if decoded != nil {
+		t.Fatalf("decoded empty proof as %+v, want nil", decoded)
+	}
+}
+
+func TestProof_JSONRoundTrip(t *testing.T) {
+	nt := NewTree(valsN(5))
+	proof, err := nt.Proof(nt.Leaves[1].Hash)
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	var decoded Proof
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if !nt.VerifyProof(nt.Leaves[1].Hash, decoded) {
+		t.Fatal("JSON round-tripped proof failed to verify")
+	}
+}
+
+func TestTree_BinaryRoundTrip(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Hash: sha256.New},
+		{Hash: sha256.New, RFC6962: true},
+	} {
+		nt := NewTreeWithOptions(valsN(9), opts)
+
+		data, err := nt.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary: %v", err)
+		}
+
+		var decoded Tree
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary: %v", err)
+		}
+		if !bytes.Equal(decoded.Root.Hash, nt.Root.Hash) {
+			t.Fatalf("decoded root = %x, want %x", decoded.Root.Hash, nt.Root.Hash)
+		}
+		if !decoded.Verify() {
+			t.Fatal("decoded tree failed Verify()")
+		}
+	}
+}
+
+func TestTree_JSONRoundTrip(t *testing.T) {
+	nt := NewTreeWithOptions(valsN(9), Options{Hash: sha256.New, RFC6962: true})
+
+	data, err := json.Marshal(nt)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	var decoded Tree
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if !bytes.Equal(decoded.Root.Hash, nt.Root.Hash) {
+		t.Fatalf("decoded root = %x, want %x", decoded.Root.Hash, nt.Root.Hash)
+	}
+	if !decoded.Verify() {
+		t.Fatal("decoded tree failed Verify()")
+	}
+}
+
+// TestTree_AlgoIDRoundTrip pins that the binary and JSON encodings identify
+// the hash algorithm itself, not just its output size: a tree built with
+// SHA-256 must decode back to SHA-256, never silently as the default
+// SHA-512 (which a size-only encoding of a different 32-byte hash would).
+func TestTree_AlgoIDRoundTrip(t *testing.T) {
+	nt := NewTreeWithOptions(valsN(4), Options{Hash: sha256.New})
+
+	bin, err := nt.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var fromBinary Tree
+	if err := fromBinary.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if fromBinary.opts.hashFn()().Size() != sha256.Size {
+		t.Fatalf("binary round trip produced a %d-byte hash function, want sha256's %d", fromBinary.opts.hashFn()().Size(), sha256.Size)
+	}
+
+	js, err := json.Marshal(nt)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !bytes.Contains(js, []byte(`"algo":"sha256"`)) {
+		t.Fatalf("JSON encoding does not name the algorithm: %s", js)
+	}
+}
+
+// TestTree_UnrecognizedHashFunctionRejected pins that a hash function this
+// package cannot name is rejected at encode time rather than silently
+// mis-serialized as whatever algorithm happens to share its output size.
+func TestTree_UnrecognizedHashFunctionRejected(t *testing.T) {
+	nt := NewTreeWithOptions(valsN(4), Options{Hash: sha256.New224})
+
+	if _, err := nt.MarshalBinary(); err == nil {
+		t.Fatal("MarshalBinary succeeded for an unrecognized hash function")
+	}
+	if _, err := json.Marshal(nt); err == nil {
+		t.Fatal("json.Marshal succeeded for an unrecognized hash function")
+	}
+}
+
+func valsN(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = []byte{byte(i)}
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+)
+
+// SparseTree is a Merkle tree indexed by a fixed-width key instead of by
+// insertion order. It is represented as a full binary tree of depth
+// keyBits, where empty subtrees collapse to precomputed zero-hashes at
+// each level, so Update, Get and proof generation run in O(keyBits) time
+// and space no matter how sparse the tree is. This makes it suitable for
+// authenticated key-value maps and revocation lists, which the append-only
+// Tree cannot serve.
+type SparseTree struct {
+	opts    Options
+	keyBits int
+
+	// zero[i] is the hash of an empty subtree of height i; zero[0] is the
+	// hash of an empty leaf.
+	zero [][]byte
+
+	// nodes holds every non-empty node, keyed by nodeKey(depth, key).
+	nodes map[string][]byte
+	// values holds the raw value stored at each occupied key.
+	values map[string][]byte
+
+	root []byte
+}
+
+// NewSparseTree creates an empty SparseTree whose keys are keyBits wide
+// (e.g. 256 for a 32-byte key space).
+func NewSparseTree(keyBits int, opts Options) *SparseTree {
+	zero := make([][]byte, keyBits+1)
+	zero[0] = opts.hashLeaf(nil)
+	for i := 1; i <= keyBits; i++ {
+		zero[i] = opts.hashNode(zero[i-1], zero[i-1])
+	}
+
+	return &SparseTree{
+		opts:    opts,
+		keyBits: keyBits,
+		zero:    zero,
+		nodes:   make(map[string][]byte),
+		values:  make(map[string][]byte),
+		root:    zero[keyBits],
+	}
+}
+
+// OpenSparseTree reconstructs a SparseTree's verification-only state (its
+// zero-hashes and options) from an externally known root hash, so a
+// verifier holding only the root can check inclusion and non-inclusion
+// proofs without any of the tree's data.
+func OpenSparseTree(keyBits int, opts Options, root []byte) *SparseTree {
+	t := NewSparseTree(keyBits, opts)
+	t.root = root
+	return t
+}
+
+// Root returns the tree's current root hash.
+func (t *SparseTree) Root() []byte {
+	return t.root
+}
+
+// Update sets the value stored at key, recomputing the O(keyBits) nodes on
+// the path from the leaf up to the root.
+func (t *SparseTree) Update(key, value []byte) error {
+	if err := t.checkKeyWidth(key); err != nil {
+		return err
+	}
+
+	current := t.opts.hashLeaf(value)
+	t.nodes[nodeKey(t.keyBits, key)] = current
+	t.values[string(key)] = append([]byte(nil), value...)
+
+	for level := t.keyBits; level >= 1; level-- {
+		sibling := t.siblingHash(level, key)
+
+		if bit(key, level-1) == 0 {
+			current = t.opts.hashNode(current, sibling)
+		} else {
+			current = t.opts.hashNode(sibling, current)
+		}
+
+		t.nodes[nodeKey(level-1, key)] = current
+	}
+
+	t.root = current
+	return nil
+}
+
+// Get returns the value stored at key and whether key is occupied.
+func (t *SparseTree) Get(key []byte) ([]byte, bool) {
+	v, ok := t.values[string(key)]
+	return v, ok
+}
+
+// ProveInclusion builds an inclusion proof for the value currently stored
+// at key: the sibling hash at every level from the leaf up to the root.
+func (t *SparseTree) ProveInclusion(key []byte) (Proof, error) {
+	if err := t.checkKeyWidth(key); err != nil {
+		return nil, err
+	}
+	if _, ok := t.values[string(key)]; !ok {
+		return nil, errors.New("merkle: key is not present in the tree")
+	}
+
+	return t.siblingPath(key), nil
+}
+
+// NonInclusionProof proves that a key's slot in a SparseTree is empty: the
+// sibling path up to the root, plus the precomputed hash of the empty leaf
+// that a verifier should plug in at the bottom of that path.
+type NonInclusionProof struct {
+	Path      Proof
+	EmptyHash []byte
+}
+
+// ProveNonInclusion proves that key is not present in the tree.
+func (t *SparseTree) ProveNonInclusion(key []byte) (*NonInclusionProof, error) {
+	if err := t.checkKeyWidth(key); err != nil {
+		return nil, err
+	}
+	if _, ok := t.values[string(key)]; ok {
+		return nil, errors.New("merkle: key is present in the tree")
+	}
+
+	return &NonInclusionProof{
+		Path:      t.siblingPath(key),
+		EmptyHash: t.zero[0],
+	}, nil
+}
+
+func (t *SparseTree) siblingPath(key []byte) []PathPoint {
+	path := make([]PathPoint, 0, t.keyBits)
+
+	for level := t.keyBits; level >= 1; level-- {
+		path = append(path, PathPoint{
+			Hash:     t.siblingHash(level, key),
+			Appended: bit(key, level-1) == 0,
+		})
+	}
+
+	return path
+}
+
+// VerifyProof recomputes the root from leafHash and path, returning true
+// iff it matches Root(). Pass opts.hashLeaf(value) as leafHash to check an
+// inclusion proof, or a NonInclusionProof's EmptyHash to check a
+// non-inclusion proof.
+func (t *SparseTree) VerifyProof(leafHash []byte, path []PathPoint) bool {
+	result := leafHash
+
+	for _, point := range path {
+		if point.Appended {
+			result = t.opts.hashNode(result, point.Hash)
+		} else {
+			result = t.opts.hashNode(point.Hash, result)
+		}
+	}
+
+	return bytes.Equal(t.root, result)
+}
+
+// siblingHash returns the hash of the node sharing key's first level-1
+// bits but differing in bit level-1, i.e. the sibling of the node at depth
+// level reached by key, or the zero-hash for that height if it is empty.
+func (t *SparseTree) siblingHash(level int, key []byte) []byte {
+	sibling := append([]byte(nil), key...)
+	flipBit(sibling, level-1)
+
+	if h, ok := t.nodes[nodeKey(level, sibling)]; ok {
+		return h
+	}
+	return t.zero[t.keyBits-level]
+}
+
+func (t *SparseTree) checkKeyWidth(key []byte) error {
+	if len(key) != (t.keyBits+7)/8 {
+		return errors.New("merkle: key has the wrong width")
+	}
+	return nil
+}
+
+// nodeKey identifies the node reached by following the first level bits of
+// key, starting from the root.
+func nodeKey(level int, key []byte) string {
+	nbytes := level / 8
+	rem := level % 8
+
+	buf := make([]byte, nbytes, nbytes+3)
+	copy(buf, key[:nbytes])
+	if rem > 0 {
+		mask := byte(0xFF << uint(8-rem))
+		buf = append(buf, key[nbytes]&mask)
+	}
+
+	return string(append([]byte{byte(level), byte(level >> 8)}, buf...))
+}
+
+func bit(key []byte, i int) int {
+	return int(key[i/8]>>uint(7-i%8)) & 1
+}
+
+func flipBit(key []byte, i int) {
+	key[i/8] ^= 1 << uint(7-i%8)
+}
@@ -0,0 +1,101 @@
+package merkle
+
+import "testing"
+
+func TestSparseTree_InclusionProof(t *testing.T) {
+	st := NewSparseTree(8, Options{})
+
+	key := []byte{0x2a}
+	value := []byte("hello")
+	if err := st.Update(key, value); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	proof, err := st.ProveInclusion(key)
+	if err != nil {
+		t.Fatalf("ProveInclusion: %v", err)
+	}
+
+	leafHash := st.opts.hashLeaf(value)
+	if !st.VerifyProof(leafHash, proof) {
+		t.Fatal("inclusion proof did not verify against the tree's root")
+	}
+
+	if st.VerifyProof(st.opts.hashLeaf([]byte("wrong value")), proof) {
+		t.Fatal("inclusion proof verified for a value that was never stored at key")
+	}
+}
+
+func TestSparseTree_NonInclusionProof(t *testing.T) {
+	st := NewSparseTree(8, Options{})
+
+	occupied := []byte{0x2a}
+	if err := st.Update(occupied, []byte("hello")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	empty := []byte{0x2b}
+	proof, err := st.ProveNonInclusion(empty)
+	if err != nil {
+		t.Fatalf("ProveNonInclusion: %v", err)
+	}
+
+	if !st.VerifyProof(proof.EmptyHash, proof.Path) {
+		t.Fatal("non-inclusion proof did not verify against the tree's root")
+	}
+
+	if _, err := st.ProveInclusion(empty); err == nil {
+		t.Fatal("ProveInclusion succeeded for a key that was never set")
+	}
+	if _, err := st.ProveNonInclusion(occupied); err == nil {
+		t.Fatal("ProveNonInclusion succeeded for a key that is occupied")
+	}
+}
+
+// TestSparseTree_EmptySubtreesCollapse pins the space/time guarantee the
+// type is built for: two keys that differ only below an untouched subtree
+// must share that subtree's precomputed zero-hash, rather than the tree
+// materializing a real node for every one of the 2^keyBits possible keys.
+func TestSparseTree_EmptySubtreesCollapse(t *testing.T) {
+	st := NewSparseTree(8, Options{})
+
+	if err := st.Update([]byte{0x00}, []byte("only value")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	proofA, err := st.ProveNonInclusion([]byte{0x80})
+	if err != nil {
+		t.Fatalf("ProveNonInclusion(0x80): %v", err)
+	}
+	proofB, err := st.ProveNonInclusion([]byte{0xFF})
+	if err != nil {
+		t.Fatalf("ProveNonInclusion(0xFF): %v", err)
+	}
+
+	if len(st.nodes) >= 1<<8 {
+		t.Fatalf("tree materialized %d nodes for a single occupied key; empty subtrees should collapse to shared zero-hashes", len(st.nodes))
+	}
+
+	if !st.VerifyProof(proofA.EmptyHash, proofA.Path) || !st.VerifyProof(proofB.EmptyHash, proofB.Path) {
+		t.Fatal("non-inclusion proof over collapsed empty subtrees failed to verify")
+	}
+}
+
+func TestSparseTree_OpenSparseTreeVerifiesWithoutData(t *testing.T) {
+	st := NewSparseTree(8, Options{})
+	key := []byte{0x10}
+	value := []byte("value")
+	if err := st.Update(key, value); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	proof, err := st.ProveInclusion(key)
+	if err != nil {
+		t.Fatalf("ProveInclusion: %v", err)
+	}
+
+	verifier := OpenSparseTree(8, Options{}, st.Root())
+	if !verifier.VerifyProof(st.opts.hashLeaf(value), proof) {
+		t.Fatal("a verifier holding only the root failed to verify a valid inclusion proof")
+	}
+}
@@ -0,0 +1,131 @@
+package merkle
+
+// stackNode is one entry in a stack of subtree roots: node is the subtree's
+// root, and level is its height (0 for a leaf). Builder, CachedTree and
+// root all build trees by pushing leaves through pushStack and collapsing
+// the result through finalizeStack, so they always agree on the shape of
+// the tree for a given leaf order.
+type stackNode struct {
+	node  *Node
+	level int
+}
+
+// pushStack pushes node onto stack as a new level-0 entry and collapses the
+// top two entries, bottom-up, for as long as they sit at the same level.
+func pushStack(stack []*stackNode, node *Node, opts Options) []*stackNode {
+	return pushStackNotify(stack, node, opts, nil)
+}
+
+// pushStackNotify behaves exactly like pushStack, additionally invoking
+// onCreate (if non-nil) with every new internal node as it is combined, so
+// callers that persist nodes as they're built (PersistentTree) don't have
+// to re-walk the resulting tree afterward to find them.
+func pushStackNotify(stack []*stackNode, node *Node, opts Options, onCreate func(*Node)) []*stackNode {
+	stack = append(stack, &stackNode{node: node, level: 0})
+
+	for len(stack) >= 2 {
+		top := stack[len(stack)-1]
+		below := stack[len(stack)-2]
+
+		if top.level != below.level {
+			break
+		}
+
+		parent := &Node{
+			Relationships: Relationships{Left: below.node, Right: top.node},
+			Typ:           TreeNode,
+			Hash:          opts.hashNode(below.node.Hash, top.node.Hash),
+		}
+		below.node.Parent = parent
+		top.node.Parent = parent
+		if onCreate != nil {
+			onCreate(parent)
+		}
+
+		stack = stack[:len(stack)-2]
+		stack = append(stack, &stackNode{node: parent, level: top.level + 1})
+	}
+
+	return stack
+}
+
+// finalizeStack collapses the stack's remaining entries into a single
+// root, folding from the most recently pushed (rightmost, lowest) entry
+// up through the oldest (leftmost, highest): each earlier entry becomes
+// the left sibling of everything folded in so far. It returns nil for an
+// empty stack.
+func finalizeStack(stack []*stackNode, opts Options) *Node {
+	return finalizeStackNotify(stack, opts, nil)
+}
+
+// finalizeStackNotify behaves exactly like finalizeStack, additionally
+// invoking onCreate (if non-nil) with every new internal node as it is
+// folded in, for callers that persist nodes as they're built.
+func finalizeStackNotify(stack []*stackNode, opts Options, onCreate func(*Node)) *Node {
+	if len(stack) == 0 {
+		return nil
+	}
+
+	acc := stack[len(stack)-1].node
+	for i := len(stack) - 2; i >= 0; i-- {
+		left := stack[i].node
+
+		parent := &Node{
+			Relationships: Relationships{Left: left, Right: acc},
+			Typ:           TreeNode,
+			Hash:          opts.hashNode(left.Hash, acc.Hash),
+		}
+		left.Parent = parent
+		acc.Parent = parent
+		if onCreate != nil {
+			onCreate(parent)
+		}
+		acc = parent
+	}
+
+	return acc
+}
+
+// finalizeStackPeek computes the same folded root hash as finalizeStack,
+// without mutating any node's Parent pointer, for callers (like
+// CachedTree) whose stack may still grow with further pushes after this
+// call.
+func finalizeStackPeek(stack []*stackNode, opts Options) []byte {
+	if len(stack) == 0 {
+		return nil
+	}
+
+	acc := stack[len(stack)-1].node.Hash
+	for i := len(stack) - 2; i >= 0; i-- {
+		acc = opts.hashNode(stack[i].node.Hash, acc)
+	}
+
+	return acc
+}
+
+// finalizeStackProofPeek computes the same crossing-stack proof path as
+// finalizeStackProof, without mutating any node's Parent pointer.
+func finalizeStackProofPeek(stack []*stackNode, idx int, opts Options) []PathPoint {
+	if len(stack) == 0 {
+		return nil
+	}
+
+	var path []PathPoint
+	onPath := idx == len(stack)-1
+	accHash := stack[len(stack)-1].node.Hash
+
+	for i := len(stack) - 2; i >= 0; i-- {
+		leftHash := stack[i].node.Hash
+
+		if idx == i {
+			path = append(path, PathPoint{Hash: accHash, Appended: true})
+			onPath = true
+		} else if onPath {
+			path = append(path, PathPoint{Hash: leftHash, Appended: false})
+		}
+
+		accHash = opts.hashNode(leftHash, accHash)
+	}
+
+	return path
+}
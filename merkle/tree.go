@@ -2,7 +2,6 @@ package merkle
 
 import (
 	"bytes"
-	"crypto/sha512"
 	"errors"
 )
 
@@ -10,6 +9,8 @@ import (
 type Tree struct {
 	Root   *Node
 	Leaves []*Node
+
+	opts Options
 }
 
 // NodeType denotes the type of the node
@@ -35,67 +36,57 @@ type Node struct {
 	Typ NodeType
 	// Hash is the hash of the children if the type is not Leaf, otherwise is the hash of the contents.
 	Hash []byte
+	// Content is the domain object this leaf was built from, set only for
+	// leaves created via NewTreeFromContent.
+	Content Content
 }
 
-// NewTree constructs a new MerkleTree from the given values.
+// NewTree constructs a new MerkleTree from the given values using the
+// default options (SHA-512, no RFC 6962 domain separation).
 func NewTree(values [][]byte) *Tree {
-	root, leaves := construct(values)
+	return NewTreeWithOptions(values, Options{})
+}
+
+// NewTreeWithOptions constructs a new MerkleTree from the given values using
+// the provided Options, letting callers choose the hash function and opt
+// into RFC 6962 domain-separated hashing.
+func NewTreeWithOptions(values [][]byte, opts Options) *Tree {
+	r, leaves := construct(values, opts)
 	return &Tree{
-		Root:   root,
+		Root:   r,
 		Leaves: leaves,
+		opts:   opts,
 	}
 }
 
-func construct(values [][]byte) (*Node, []*Node) {
+func construct(values [][]byte, opts Options) (*Node, []*Node) {
 	leaves := make([]*Node, len(values))
 
 	for i := range leaves {
-		h := sha512.Sum512(values[i])
-
 		leaves[i] = &Node{
 			Relationships: Relationships{},
 			Typ:           Leaf,
-			Hash:          h[:],
+			Hash:          opts.hashLeaf(values[i]),
 		}
 	}
 
-	if len(leaves)%2 == 1 {
-		h := sha512.Sum512(values[len(values)-1])
-
-		leaves = append(leaves, &Node{
-			Relationships: Relationships{},
-			Typ:           Leaf,
-			Hash:          h[:],
-		})
-	}
-
-	return root(leaves), leaves
+	return root(leaves, opts), leaves
 }
 
-func root(queue []*Node) *Node {
-	for len(queue) != 1 {
-		left, right := queue[0], queue[1]
-		queue = queue[2:]
-
-		h := sha512.Sum512(append(left.Hash, right.Hash...))
-
-		node := &Node{
-			Relationships: Relationships{
-				Parent: nil,
-				Left:   left,
-				Right:  right,
-			},
-			Typ:  TreeNode,
-			Hash: h[:],
-		}
-
-		left.Parent = node
-		right.Parent = node
-
-		queue = append(queue, node)
+// root builds a tree over leaves by pushing them through pushStack in
+// order and collapsing the result with finalizeStack, the same way Builder
+// and CachedTree do, so a Tree, a Builder and a CachedTree built from the
+// same ordered leaves always agree on the root. A trailing subtree with no
+// same-level sibling is folded in unchanged rather than paired with a
+// duplicate of itself, so that trees built from different input sets can
+// never produce the same root. Returns nil for an empty leaves slice.
+func root(leaves []*Node, opts Options) *Node {
+	var stack []*stackNode
+	for _, leaf := range leaves {
+		stack = pushStack(stack, leaf, opts)
 	}
 
-	return queue[0]
+	return finalizeStack(stack, opts)
 }
 
 // Verify rebuilds the tree and verifies the integrity.
@@ -104,8 +95,8 @@ func (tree *Tree) Verify() bool {
 		return false
 	}
 
-	root := root(append([]*Node(nil), tree.Leaves...))
-	return bytes.Equal(tree.Root.Hash, root.Hash)
+	r := root(append([]*Node(nil), tree.Leaves...), tree.opts)
+	return bytes.Equal(tree.Root.Hash, r.Hash)
 }
 
 // VerifyProof verifies that h part of the merkle tree.
@@ -114,11 +105,9 @@ func (tree *Tree) VerifyProof(h []byte, path []PathPoint) bool {
 
 	for _, point := range path {
 		if point.Appended {
-			tmp := sha512.Sum512(append(result, point.Hash...))
-			result = tmp[:]
+			result = tree.opts.hashNode(result, point.Hash)
 		} else {
-			tmp := sha512.Sum512(append(point.Hash, result...))
-			result = tmp[:]
+			result = tree.opts.hashNode(point.Hash, result)
 		}
 	}
 
@@ -131,7 +120,7 @@ type PathPoint struct {
 }
 
 // Proof builds a proof for a MerkleTree
-func (tree *Tree) Proof(h []byte) ([]PathPoint, error) {
+func (tree *Tree) Proof(h []byte) (Proof, error) {
 	if tree == nil {
 		return nil, errors.New("empty tree")
 	}
@@ -141,9 +130,13 @@ func (tree *Tree) Proof(h []byte) ([]PathPoint, error) {
 		return nil, errors.New("no node with such hash")
 	}
 
+	return proofFromNode(current), nil
+}
+
+// proofFromNode collects all the siblings from current up to the root.
+func proofFromNode(current *Node) []PathPoint {
 	var path []PathPoint
 
-	// collect all the siblings until the root is reached.
 	parent := current.Parent
 	for parent != nil {
 		if current == parent.Left {
@@ -162,7 +155,7 @@ func (tree *Tree) Proof(h []byte) ([]PathPoint, error) {
 		parent = current.Parent
 	}
 
-	return path, nil
+	return path
 }
 
 func findNodeWithHash(nodes []*Node, h []byte) *Node {